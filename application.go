@@ -0,0 +1,127 @@
+/*
+Copyright 2014 Rohith All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package marathon
+
+// Application is the definition for an application in marathon
+type Application struct {
+	ID               string            `json:"id,omitempty"`
+	Container        *Container        `json:"container,omitempty"`
+	Residency        *Residency        `json:"residency,omitempty"`
+	UpgradeStrategy  *UpgradeStrategy  `json:"upgradeStrategy,omitempty"`
+	IPAddressPerTask *IPAddressPerTask `json:"ipAddress,omitempty"`
+	PortDefinitions  *[]PortDefinition `json:"portDefinitions,omitempty"`
+}
+
+// AddPortDefinition declares a port Marathon should allocate and advertise at the
+// application level -- required for MESOS (UCR) containers on HOST/BRIDGE networking,
+// which have no container-level port mappings of their own to declare it on
+//		number:			the port number, or 0 to let Marathon assign one
+//		name:				the name to expose the port under, for symbolic lookups
+//		protocol:		the protocol to use, e.g. "tcp"
+//		labels:			labels to attach to the port definition
+func (application *Application) AddPortDefinition(number int, name, protocol string, labels map[string]string) *Application {
+	if application.PortDefinitions == nil {
+		application.EmptyPortDefinitions()
+	}
+
+	portDefinitions := *application.PortDefinitions
+	portDefinitions = append(portDefinitions, PortDefinition{
+		Number:   number,
+		Name:     name,
+		Protocol: protocol,
+		Labels:   labels,
+	})
+	application.PortDefinitions = &portDefinitions
+
+	return application
+}
+
+// EmptyPortDefinitions explicitly empties the port definitions -- use this if you need to
+// empty port definitions that are already set (setting port definitions to nil will keep
+// the current value)
+func (application *Application) EmptyPortDefinitions() *Application {
+	application.PortDefinitions = &[]PortDefinition{}
+	return application
+}
+
+// IPAddressPerTask is the definition for per-task IP address allocation, used to attach
+// an application's containers to a CNI network when the container's Docker network mode
+// is USER
+type IPAddressPerTask struct {
+	Groups      []string          `json:"groups,omitempty"`
+	Labels      map[string]string `json:"labels,omitempty"`
+	NetworkName string            `json:"networkName,omitempty"`
+	Discovery   *Discovery        `json:"discovery,omitempty"`
+}
+
+// Discovery is the discovery info block of an IPAddressPerTask, listing the ports that
+// Mesos-DNS and other service-discovery integrations should resolve for the application
+type Discovery struct {
+	Ports []PortDefinition `json:"ports,omitempty"`
+}
+
+// PortDefinition is a single port published through an IPAddressPerTask's Discovery block
+type PortDefinition struct {
+	Number   int               `json:"number,omitempty"`
+	Name     string            `json:"name,omitempty"`
+	Protocol string            `json:"protocol,omitempty"`
+	Labels   map[string]string `json:"labels,omitempty"`
+}
+
+// UserNetwork attaches the application to the named CNI network and publishes the given
+// ports for discovery -- pair with Docker.UserNetwork on the application's container
+//		name:			the CNI network to attach to
+//		ports:		the ports to publish in the discovery block
+func (application *Application) UserNetwork(name string, ports ...PortDefinition) *Application {
+	application.IPAddressPerTask = &IPAddressPerTask{
+		NetworkName: name,
+		Discovery: &Discovery{
+			Ports: ports,
+		},
+	}
+
+	return application
+}
+
+// Residency is the definition for task residency, required by Marathon on any application
+// whose container has a local persistent volume attached
+type Residency struct {
+	RelaunchEscalationTimeoutSeconds int    `json:"relaunchEscalationTimeoutSeconds,omitempty"`
+	TaskLostBehavior                 string `json:"taskLostBehavior,omitempty"`
+}
+
+// UpgradeStrategy is the definition for the rolling upgrade strategy of an application
+type UpgradeStrategy struct {
+	MinimumHealthCapacity float64 `json:"minimumHealthCapacity"`
+	MaximumOverCapacity   float64 `json:"maximumOverCapacity"`
+}
+
+// EnsureResidency sets Residency and the UpgradeStrategy Marathon requires on an application
+// whose container has a local persistent volume attached -- without these Marathon rejects
+// the application outright
+func (application *Application) EnsureResidency() *Application {
+	if application.Residency == nil {
+		application.Residency = &Residency{}
+	}
+
+	application.UpgradeStrategy = &UpgradeStrategy{
+		MinimumHealthCapacity: 0.5,
+		MaximumOverCapacity:   0,
+	}
+
+	return application
+}
@@ -0,0 +1,92 @@
+/*
+Copyright 2014 Rohith All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package marathon
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestAddPortDefinitionAppends(t *testing.T) {
+	application := &Application{}
+	application.AddPortDefinition(0, "http", "tcp", map[string]string{"VIP_0": "/foo:80"})
+	application.AddPortDefinition(0, "admin", "tcp", nil)
+
+	data, err := json.Marshal(application.PortDefinitions)
+	if err != nil {
+		t.Fatalf("failed to marshal port definitions: %s", err)
+	}
+
+	expected := `[{"name":"http","protocol":"tcp","labels":{"VIP_0":"/foo:80"}},{"name":"admin","protocol":"tcp"}]`
+	if got := string(data); got != expected {
+		t.Errorf("expected %s, got %s", expected, got)
+	}
+}
+
+func TestEmptyPortDefinitions(t *testing.T) {
+	application := &Application{}
+	application.AddPortDefinition(80, "http", "tcp", nil)
+	application.EmptyPortDefinitions()
+
+	if application.PortDefinitions == nil || len(*application.PortDefinitions) != 0 {
+		t.Errorf("expected an empty, non-nil port definitions slice, got %+v", application.PortDefinitions)
+	}
+}
+
+func TestEnsureResidencySetsResidencyAndUpgradeStrategy(t *testing.T) {
+	application := &Application{}
+	application.EnsureResidency()
+
+	data, err := json.Marshal(application)
+	if err != nil {
+		t.Fatalf("failed to marshal application: %s", err)
+	}
+
+	expected := `{"residency":{},"upgradeStrategy":{"minimumHealthCapacity":0.5,"maximumOverCapacity":0}}`
+	if got := string(data); got != expected {
+		t.Errorf("expected %s, got %s", expected, got)
+	}
+}
+
+func TestEnsureResidencyPreservesExistingResidencyDetails(t *testing.T) {
+	application := &Application{
+		Residency: &Residency{TaskLostBehavior: "WAIT_FOREVER"},
+	}
+	application.EnsureResidency()
+
+	if application.Residency.TaskLostBehavior != "WAIT_FOREVER" {
+		t.Errorf("expected existing residency details to be preserved, got %+v", application.Residency)
+	}
+	if application.UpgradeStrategy == nil || application.UpgradeStrategy.MinimumHealthCapacity != 0.5 {
+		t.Errorf("expected upgrade strategy to be set, got %+v", application.UpgradeStrategy)
+	}
+}
+
+func TestApplicationUserNetworkSetsIPAddressPerTask(t *testing.T) {
+	application := &Application{}
+	application.UserNetwork("mynet", PortDefinition{Number: 80, Name: "http", Protocol: "tcp"})
+
+	data, err := json.Marshal(application.IPAddressPerTask)
+	if err != nil {
+		t.Fatalf("failed to marshal ip address per task: %s", err)
+	}
+
+	expected := `{"networkName":"mynet","discovery":{"ports":[{"number":80,"name":"http","protocol":"tcp"}]}}`
+	if got := string(data); got != expected {
+		t.Errorf("expected %s, got %s", expected, got)
+	}
+}
@@ -19,21 +19,25 @@ package marathon
 import (
 	"errors"
 	"fmt"
+	"strings"
 )
 
 // Container is the definition for a container type in marathon
 type Container struct {
-	Type    string    `json:"type,omitempty"`
-	Docker  *Docker   `json:"docker,omitempty"`
-	Volumes *[]Volume `json:"volumes,omitempty"`
+	Type    string          `json:"type,omitempty"`
+	Docker  *Docker         `json:"docker,omitempty"`
+	Mesos   *MesosContainer `json:"mesos,omitempty"`
+	Volumes *[]Volume       `json:"volumes,omitempty"`
 }
 
 // PortMapping is the portmapping structure between container and mesos
 type PortMapping struct {
-	ContainerPort int    `json:"containerPort,omitempty"`
-	HostPort      int    `json:"hostPort"`
-	ServicePort   int    `json:"servicePort,omitempty"`
-	Protocol      string `json:"protocol"`
+	ContainerPort int               `json:"containerPort,omitempty"`
+	HostPort      *int              `json:"hostPort,omitempty"`
+	ServicePort   int               `json:"servicePort,omitempty"`
+	Protocol      string            `json:"protocol"`
+	Name          string            `json:"name,omitempty"`
+	Labels        map[string]string `json:"labels,omitempty"`
 }
 
 // Parameters is the parameters to pass to the docker client when creating the container
@@ -44,9 +48,51 @@ type Parameters struct {
 
 // Volume is the docker volume details associated to the container
 type Volume struct {
-	ContainerPath string `json:"containerPath,omitempty"`
-	HostPath      string `json:"hostPath,omitempty"`
-	Mode          string `json:"mode,omitempty"`
+	ContainerPath string            `json:"containerPath,omitempty"`
+	HostPath      string            `json:"hostPath,omitempty"`
+	Mode          string            `json:"mode,omitempty"`
+	Persistent    *PersistentVolume `json:"persistent,omitempty"`
+	External      *ExternalVolume   `json:"external,omitempty"`
+}
+
+// PersistentVolume is the definition for a Marathon local persistent volume. Applications
+// using a persistent volume must also set Residency and an UpgradeStrategy that disables
+// over-provisioning, see Application.EnsureResidency
+type PersistentVolume struct {
+	Size        int         `json:"size"`
+	Type        string      `json:"type,omitempty"`
+	MaxSize     int         `json:"maxSize,omitempty"`
+	Constraints *[][]string `json:"constraints,omitempty"`
+}
+
+// ExternalVolume is the definition for an external volume mounted through the DVDI
+// (Docker Volume Driver Interface) isolator, e.g. a REX-Ray backed volume
+type ExternalVolume struct {
+	Name     string            `json:"name,omitempty"`
+	Provider string            `json:"provider,omitempty"`
+	Options  map[string]string `json:"options,omitempty"`
+}
+
+// Image is the image to run inside a Mesos Universal Container Runtime (UCR) container
+type Image struct {
+	Kind      string            `json:"kind,omitempty"`
+	ID        string            `json:"id,omitempty"`
+	Labels    map[string]string `json:"labels,omitempty"`
+	ForcePull bool              `json:"forcePull,omitempty"`
+}
+
+// Credential is the principal/secret pair used to pull an Image from a private registry
+type Credential struct {
+	Principal string `json:"principal,omitempty"`
+	Secret    string `json:"secret,omitempty"`
+}
+
+// MesosContainer is the definition for a Mesos Universal Container Runtime (UCR) container.
+// Unlike the Docker containerizer, UCR has no portMappings of its own -- ports are declared
+// on the application's PortDefinitions or IPAddress.Discovery.Ports instead
+type MesosContainer struct {
+	Image      *Image      `json:"image,omitempty"`
+	Credential *Credential `json:"credential,omitempty"`
 }
 
 // Docker is the docker definition from a marathon application
@@ -88,6 +134,58 @@ func (container *Container) EmptyVolumes() *Container {
 	return container
 }
 
+// PersistentVolume attaches a local persistent volume to the container -- the owning
+// application must also call EnsureResidency or Marathon will reject it
+//		containerPath:		the path inside the container to mount the volume
+//		sizeMB:					the size of the volume to request, in MB
+//		ptype:					the type of disk to request -- root, path or mount
+func (container *Container) PersistentVolume(containerPath string, sizeMB int, ptype string) *Container {
+	if container.Volumes == nil {
+		container.EmptyVolumes()
+	}
+
+	volumes := *container.Volumes
+	volumes = append(volumes, Volume{
+		ContainerPath: containerPath,
+		Mode:          "RW",
+		Persistent: &PersistentVolume{
+			Size: sizeMB,
+			Type: ptype,
+		},
+	})
+
+	container.Volumes = &volumes
+
+	return container
+}
+
+// ExternalVolume attaches an external volume to the container via the DVDI isolator
+//		containerPath:		the path inside the container to mount the volume
+//		name:							the name of the external volume
+//		provider:					the DVDI volume provider, e.g. "dvdi"
+//		options:					provider specific options, e.g. "dvdi/driver": "rexray"
+//		mode:							the mode to map the container
+func (container *Container) ExternalVolume(containerPath, name, provider string, options map[string]string, mode string) *Container {
+	if container.Volumes == nil {
+		container.EmptyVolumes()
+	}
+
+	volumes := *container.Volumes
+	volumes = append(volumes, Volume{
+		ContainerPath: containerPath,
+		Mode:          mode,
+		External: &ExternalVolume{
+			Name:     name,
+			Provider: provider,
+			Options:  options,
+		},
+	})
+
+	container.Volumes = &volumes
+
+	return container
+}
+
 // NewDockerContainer creates a default docker container for you
 func NewDockerContainer() *Container {
 	container := &Container{}
@@ -97,6 +195,52 @@ func NewDockerContainer() *Container {
 	return container
 }
 
+// NewMesosContainer creates a default Mesos Universal Container Runtime (UCR) container for you
+func NewMesosContainer() *Container {
+	container := &Container{}
+	container.Type = "MESOS"
+	container.Mesos = &MesosContainer{}
+
+	return container
+}
+
+// Container sets the image the Mesos UCR container should run
+//		kind:			the kind of image -- DOCKER or APPC
+//		id:				the image reference, e.g. a docker registry image name
+func (mesos *MesosContainer) Container(kind, id string) *MesosContainer {
+	if mesos.Image == nil {
+		mesos.Image = &Image{}
+	}
+	mesos.Image.Kind = kind
+	mesos.Image.ID = id
+
+	return mesos
+}
+
+// SetForcePullImage sets whether the image should always be force pulled before
+// starting an instance
+//		forcePull:			true / false
+func (mesos *MesosContainer) SetForcePullImage(forcePull bool) *MesosContainer {
+	if mesos.Image == nil {
+		mesos.Image = &Image{}
+	}
+	mesos.Image.ForcePull = forcePull
+
+	return mesos
+}
+
+// SetCredential sets the principal/secret used to pull the image from a private registry
+//		principal:			the registry principal
+//		secret:					the registry secret
+func (mesos *MesosContainer) SetCredential(principal, secret string) *MesosContainer {
+	mesos.Credential = &Credential{
+		Principal: principal,
+		Secret:    secret,
+	}
+
+	return mesos
+}
+
 // SetForcePullImage sets whether the docker image should always be force pulled before
 // starting an instance
 //		forcePull:			true / false
@@ -122,12 +266,60 @@ func (docker *Docker) Container(image string) *Docker {
 	return docker
 }
 
+// Host sets the networking mode to host, sharing the host's network namespace. Any port
+// mappings already added are re-derived for the new mode -- see hostPortValue
+func (docker *Docker) Host() *Docker {
+	docker.Network = "HOST"
+	docker.reapplyNetworkToPortMappings()
+	return docker
+}
+
+// BridgeMode sets the networking mode to bridged. Any port mappings already added are
+// re-derived for the new mode -- see hostPortValue
+func (docker *Docker) BridgeMode() *Docker {
+	docker.Network = "BRIDGE"
+	docker.reapplyNetworkToPortMappings()
+	return docker
+}
+
 // Bridged sets the networking mode to bridged
+//
+// Deprecated: use BridgeMode -- Bridged previously set the network to HOST in error
 func (docker *Docker) Bridged() *Docker {
-	docker.Network = "HOST"
+	return docker.BridgeMode()
+}
+
+// UserNetwork sets the networking mode to USER, for a container attached to a CNI network.
+// The name is not recorded on the Docker definition: pair this with Application.UserNetwork,
+// whose IPAddressPerTask block carries the network name Marathon resolves. Any port
+// mappings already added are re-derived for the new mode -- see hostPortValue
+func (docker *Docker) UserNetwork(name string) *Docker {
+	docker.Network = "USER"
+	docker.reapplyNetworkToPortMappings()
 	return docker
 }
 
+// reapplyNetworkToPortMappings re-derives HostPort on every existing port mapping for the
+// networking mode now in effect. Network mode and port mappings can be set in either
+// order, so ExposePort/ExposePortNamed alone can't be trusted to have seen the final mode
+// -- without this, calling UserNetwork after ExposePort would leave a stale non-nil
+// HostPort in the payload. A mapping cleared by a prior USER network has no original
+// HostPort to restore, so switching away from USER falls back to auto-assign (0)
+func (docker *Docker) reapplyNetworkToPortMappings() {
+	if docker.PortMappings == nil {
+		return
+	}
+
+	portMappings := *docker.PortMappings
+	for i := range portMappings {
+		if portMappings[i].HostPort == nil {
+			portMappings[i].HostPort = docker.hostPortValue(0)
+			continue
+		}
+		portMappings[i].HostPort = docker.hostPortValue(*portMappings[i].HostPort)
+	}
+}
+
 // Expose sets the container to expose the following TCP ports
 //		ports:			the TCP ports the container is exposing
 func (docker *Docker) Expose(ports ...int) *Docker {
@@ -159,7 +351,7 @@ func (docker *Docker) ExposePort(containerPort, hostPort, servicePort int, proto
 	portMappings := *docker.PortMappings
 	portMappings = append(portMappings, PortMapping{
 		ContainerPort: containerPort,
-		HostPort:      hostPort,
+		HostPort:      docker.hostPortValue(hostPort),
 		ServicePort:   servicePort,
 		Protocol:      protocol})
 	docker.PortMappings = &portMappings
@@ -167,6 +359,45 @@ func (docker *Docker) ExposePort(containerPort, hostPort, servicePort int, proto
 	return docker
 }
 
+// hostPortValue returns a pointer to hostPort, or nil on a USER network -- Marathon
+// requires HostPort to be entirely absent from the JSON payload on CNI-attached
+// containers, not merely zero
+func (docker *Docker) hostPortValue(hostPort int) *int {
+	if docker.Network == "USER" {
+		return nil
+	}
+
+	return &hostPort
+}
+
+// ExposePortNamed exposes a port in the container under a name, so it can be addressed
+// symbolically (e.g. by Mesos-DNS SRV records or a "VIP_0=/foo:80" load balancer label)
+// instead of by ordinal
+//		containerPort:			the container port which is being exposed
+//		hostPort:						the host port we should expose it on
+//		servicePort:				check the marathon documentation
+//		protocol:						the protocol to use TCP, UDP
+//		name:								the name to expose the port mapping under
+//		labels:							labels to attach to the port mapping
+func (docker *Docker) ExposePortNamed(containerPort, hostPort, servicePort int, protocol, name string, labels map[string]string) *Docker {
+	if docker.PortMappings == nil {
+		docker.EmptyPortMappings()
+	}
+
+	portMappings := *docker.PortMappings
+	portMappings = append(portMappings, PortMapping{
+		ContainerPort: containerPort,
+		HostPort:      docker.hostPortValue(hostPort),
+		ServicePort:   servicePort,
+		Protocol:      protocol,
+		Name:          name,
+		Labels:        labels,
+	})
+	docker.PortMappings = &portMappings
+
+	return docker
+}
+
 // EmptyPortMappings explicitly empties the port mappings -- use this if you need to empty
 // port mappings of an application that already has port mappings set (setting port mappings to nil will
 // keep the current value)
@@ -201,6 +432,138 @@ func (docker *Docker) EmptyParameters() *Docker {
 	return docker
 }
 
+// replaceParameter removes any existing parameter matched by match and appends a fresh
+// one in its place, so repeated calls to the typed builders below replace rather than
+// pile up duplicate Docker CLI flags
+func (docker *Docker) replaceParameter(key, value string, match func(Parameters) bool) *Docker {
+	if docker.Parameters == nil {
+		docker.EmptyParameters()
+	}
+
+	parameters := make([]Parameters, 0, len(*docker.Parameters)+1)
+	for _, parameter := range *docker.Parameters {
+		if !match(parameter) {
+			parameters = append(parameters, parameter)
+		}
+	}
+	parameters = append(parameters, Parameters{Key: key, Value: value})
+	docker.Parameters = &parameters
+
+	return docker
+}
+
+// removeParameters strips every parameter with the given key, used by the builders below
+// that replace a whole set of values (e.g. DNS servers) on each call
+func (docker *Docker) removeParameters(key string) {
+	if docker.Parameters == nil {
+		docker.EmptyParameters()
+		return
+	}
+
+	parameters := make([]Parameters, 0, len(*docker.Parameters))
+	for _, parameter := range *docker.Parameters {
+		if parameter.Key != key {
+			parameters = append(parameters, parameter)
+		}
+	}
+	docker.Parameters = &parameters
+}
+
+// AddCapability adds a linux capability to the container, e.g. "NET_ADMIN"
+//		capability:			the capability to add
+func (docker *Docker) AddCapability(capability string) *Docker {
+	return docker.replaceParameter("cap-add", capability, func(p Parameters) bool {
+		return p.Key == "cap-add" && p.Value == capability
+	})
+}
+
+// DropCapability drops a linux capability from the container, e.g. "MKNOD"
+//		capability:			the capability to drop
+func (docker *Docker) DropCapability(capability string) *Docker {
+	return docker.replaceParameter("cap-drop", capability, func(p Parameters) bool {
+		return p.Key == "cap-drop" && p.Value == capability
+	})
+}
+
+// Ulimit sets a ulimit on the container, replacing any ulimit already set under the same name
+//		name:			the ulimit to set, e.g. "nofile"
+//		soft:			the soft limit
+//		hard:			the hard limit
+func (docker *Docker) Ulimit(name string, soft, hard int64) *Docker {
+	prefix := name + "="
+	value := fmt.Sprintf("%s=%d:%d", name, soft, hard)
+
+	return docker.replaceParameter("ulimit", value, func(p Parameters) bool {
+		return p.Key == "ulimit" && strings.HasPrefix(p.Value, prefix)
+	})
+}
+
+// LogDriver sets the docker log driver and its options, replacing any previously configured
+// driver and options
+//		driver:			the log driver to use, e.g. "json-file"
+//		opts:				driver specific options, e.g. "max-size": "10m"
+func (docker *Docker) LogDriver(driver string, opts map[string]string) *Docker {
+	docker.replaceParameter("log-driver", driver, func(p Parameters) bool {
+		return p.Key == "log-driver"
+	})
+
+	// step: a new driver's options are unrelated to the old driver's -- clear them all
+	// rather than replacing key-by-key, or stale options from the previous driver linger
+	docker.removeParameters("log-opt")
+	for key, value := range opts {
+		docker.AddParameter("log-opt", fmt.Sprintf("%s=%s", key, value))
+	}
+
+	return docker
+}
+
+// DNS sets the DNS servers for the container, replacing any previously configured servers
+//		servers:			the DNS servers to use
+func (docker *Docker) DNS(servers ...string) *Docker {
+	docker.removeParameters("dns")
+	for _, server := range servers {
+		docker.AddParameter("dns", server)
+	}
+
+	return docker
+}
+
+// DNSSearch sets the DNS search domains for the container, replacing any previously
+// configured domains
+//		domains:			the DNS search domains to use
+func (docker *Docker) DNSSearch(domains ...string) *Docker {
+	docker.removeParameters("dns-search")
+	for _, domain := range domains {
+		docker.AddParameter("dns-search", domain)
+	}
+
+	return docker
+}
+
+// User sets the user (and optionally group) the container runs as, replacing any
+// previously configured user
+//		spec:			the user to run as -- uid, uid:gid or name:group, per the pkg/user parsing rules
+func (docker *Docker) User(spec string) *Docker {
+	return docker.replaceParameter("user", spec, func(p Parameters) bool {
+		return p.Key == "user"
+	})
+}
+
+// Tmpfs mounts a tmpfs at path inside the container, replacing any tmpfs already
+// mounted at the same path
+//		path:			the path inside the container to mount the tmpfs at
+//		opts:			mount options for the tmpfs, e.g. "size=64m,mode=1777"
+func (docker *Docker) Tmpfs(path string, opts string) *Docker {
+	value := path
+	if opts != "" {
+		value = fmt.Sprintf("%s:%s", path, opts)
+	}
+
+	return docker.replaceParameter("tmpfs", value, func(p Parameters) bool {
+		return p.Key == "tmpfs" && (p.Value == path || strings.HasPrefix(p.Value, path+":"))
+	})
+}
+
 // ServicePortIndex finds the service port index of the exposed port
 //		port:			the port you are looking for
 func (docker *Docker) ServicePortIndex(port int) (int, error) {
@@ -218,3 +581,70 @@ func (docker *Docker) ServicePortIndex(port int) (int, error) {
 	// step: we didn't find the port in the mappings
 	return 0, fmt.Errorf("The container port required was not found in the container port mappings")
 }
+
+// PortMappingByName finds the port mapping with the given name
+//		name:			the name of the port mapping you are looking for
+func (docker *Docker) PortMappingByName(name string) (*PortMapping, error) {
+	if docker.PortMappings == nil || len(*docker.PortMappings) == 0 {
+		return nil, errors.New("The docker does not contain any port mappings to search")
+	}
+
+	// step: iterate and find the named port mapping
+	portMappings := *docker.PortMappings
+	for index, portMapping := range portMappings {
+		if portMapping.Name == name {
+			return &portMappings[index], nil
+		}
+	}
+
+	// step: we didn't find a port mapping with that name
+	return nil, fmt.Errorf("no port mapping named '%s' was found in the container port mappings", name)
+}
+
+// ServicePortByName finds the service port of the port mapping with the given name
+//		name:			the name of the port mapping you are looking for
+func (docker *Docker) ServicePortByName(name string) (int, error) {
+	portMapping, err := docker.PortMappingByName(name)
+	if err != nil {
+		return 0, err
+	}
+
+	return portMapping.ServicePort, nil
+}
+
+// ExposePort exposes a port on the container, dispatching on the container type rather
+// than assuming a Docker container. MESOS (UCR) containers have no port mappings of their
+// own -- the ports belong on the application's PortDefinitions / IPAddress.Discovery.Ports
+// instead -- so this returns an error rather than silently dropping the call
+//		containerPort:			the container port which is being exposed
+//		hostPort:						the host port we should expose it on
+//		servicePort:				check the marathon documentation
+//		protocol:						the protocol to use TCP, UDP
+func (container *Container) ExposePort(containerPort, hostPort, servicePort int, protocol string) (*Container, error) {
+	switch container.Type {
+	case "MESOS":
+		return container, errors.New("MESOS containers have no port mappings of their own -- expose the port via the application's PortDefinitions or IPAddress.Discovery.Ports instead")
+	default:
+		if container.Docker == nil {
+			container.Docker = &Docker{}
+		}
+		container.Docker.ExposePort(containerPort, hostPort, servicePort, protocol)
+	}
+
+	return container, nil
+}
+
+// ServicePortIndex finds the service port index of the exposed port, dispatching on the
+// container type rather than assuming a Docker container
+//		port:			the port you are looking for
+func (container *Container) ServicePortIndex(port int) (int, error) {
+	switch container.Type {
+	case "MESOS":
+		return 0, errors.New("MESOS containers have no port mappings of their own -- look up the port on the application's port definitions instead")
+	default:
+		if container.Docker == nil {
+			return 0, errors.New("The docker does not contain any port mappings to search")
+		}
+		return container.Docker.ServicePortIndex(port)
+	}
+}
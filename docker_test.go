@@ -0,0 +1,344 @@
+/*
+Copyright 2014 Rohith All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package marathon
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+// parametersJSON marshals a Docker's parameters to JSON for comparison against what a
+// raw Docker HostConfig would produce on the command line
+func parametersJSON(t *testing.T, docker *Docker) string {
+	data, err := json.Marshal(docker.Parameters)
+	if err != nil {
+		t.Fatalf("failed to marshal parameters: %s", err)
+	}
+	return string(data)
+}
+
+// portMappingsJSON marshals a Docker's port mappings to JSON
+func portMappingsJSON(t *testing.T, docker *Docker) string {
+	data, err := json.Marshal(docker.PortMappings)
+	if err != nil {
+		t.Fatalf("failed to marshal port mappings: %s", err)
+	}
+	return string(data)
+}
+
+func TestUserNetworkClearsHostPortOnExistingMappings(t *testing.T) {
+	docker := &Docker{}
+	docker.ExposePort(80, 8080, 0, "tcp")
+	docker.UserNetwork("mynet")
+
+	expected := `[{"containerPort":80,"protocol":"tcp"}]`
+	if got := portMappingsJSON(t, docker); got != expected {
+		t.Errorf("expected %s, got %s", expected, got)
+	}
+}
+
+func TestBridgeModeResetsHostPortToZeroAfterUserNetwork(t *testing.T) {
+	docker := &Docker{}
+	docker.ExposePort(80, 8080, 0, "tcp")
+	docker.UserNetwork("mynet")
+	docker.BridgeMode()
+
+	expected := `[{"containerPort":80,"hostPort":0,"protocol":"tcp"}]`
+	if got := portMappingsJSON(t, docker); got != expected {
+		t.Errorf("expected %s, got %s", expected, got)
+	}
+}
+
+func TestAddCapabilityIsIdempotent(t *testing.T) {
+	docker := &Docker{}
+	docker.AddCapability("NET_ADMIN")
+	docker.AddCapability("NET_ADMIN")
+	docker.AddCapability("SYS_TIME")
+
+	expected := `[{"key":"cap-add","value":"NET_ADMIN"},{"key":"cap-add","value":"SYS_TIME"}]`
+	if got := parametersJSON(t, docker); got != expected {
+		t.Errorf("expected %s, got %s", expected, got)
+	}
+}
+
+func TestDropCapabilityIsIdempotent(t *testing.T) {
+	docker := &Docker{}
+	docker.DropCapability("MKNOD")
+	docker.DropCapability("MKNOD")
+
+	expected := `[{"key":"cap-drop","value":"MKNOD"}]`
+	if got := parametersJSON(t, docker); got != expected {
+		t.Errorf("expected %s, got %s", expected, got)
+	}
+}
+
+func TestUlimitReplacesExistingValueForSameName(t *testing.T) {
+	docker := &Docker{}
+	docker.Ulimit("nofile", 1024, 2048)
+	docker.Ulimit("nofile", 4096, 8192)
+	docker.Ulimit("nproc", 100, 200)
+
+	expected := `[{"key":"ulimit","value":"nofile=4096:8192"},{"key":"ulimit","value":"nproc=100:200"}]`
+	if got := parametersJSON(t, docker); got != expected {
+		t.Errorf("expected %s, got %s", expected, got)
+	}
+}
+
+func TestLogDriverReplacesDriverAndOptions(t *testing.T) {
+	docker := &Docker{}
+	docker.LogDriver("json-file", map[string]string{"max-size": "10m"})
+	docker.LogDriver("syslog", map[string]string{"syslog-address": "udp://1.2.3.4:514"})
+
+	expected := `[{"key":"log-driver","value":"syslog"},{"key":"log-opt","value":"syslog-address=udp://1.2.3.4:514"}]`
+	if got := parametersJSON(t, docker); got != expected {
+		t.Errorf("expected %s, got %s", expected, got)
+	}
+}
+
+func TestDNSReplacesPreviousServers(t *testing.T) {
+	docker := &Docker{}
+	docker.DNS("10.0.0.1", "10.0.0.2")
+	docker.DNS("8.8.8.8")
+
+	expected := `[{"key":"dns","value":"8.8.8.8"}]`
+	if got := parametersJSON(t, docker); got != expected {
+		t.Errorf("expected %s, got %s", expected, got)
+	}
+}
+
+func TestDNSSearchReplacesPreviousDomains(t *testing.T) {
+	docker := &Docker{}
+	docker.DNSSearch("example.com", "internal")
+	docker.DNSSearch("marathon.mesos")
+
+	expected := `[{"key":"dns-search","value":"marathon.mesos"}]`
+	if got := parametersJSON(t, docker); got != expected {
+		t.Errorf("expected %s, got %s", expected, got)
+	}
+}
+
+func TestUserReplacesExistingUser(t *testing.T) {
+	docker := &Docker{}
+	docker.User("1000:1000")
+	docker.User("app:app")
+
+	expected := `[{"key":"user","value":"app:app"}]`
+	if got := parametersJSON(t, docker); got != expected {
+		t.Errorf("expected %s, got %s", expected, got)
+	}
+}
+
+func TestTmpfsReplacesExistingMountAtSamePath(t *testing.T) {
+	docker := &Docker{}
+	docker.Tmpfs("/tmp", "size=64m,mode=1777")
+	docker.Tmpfs("/tmp", "size=128m")
+	docker.Tmpfs("/run", "")
+
+	expected := `[{"key":"tmpfs","value":"/tmp:size=128m"},{"key":"tmpfs","value":"/run"}]`
+	if got := parametersJSON(t, docker); got != expected {
+		t.Errorf("expected %s, got %s", expected, got)
+	}
+}
+
+func TestNewMesosContainerSetsTypeAndEmptyImage(t *testing.T) {
+	container := NewMesosContainer()
+
+	if container.Type != "MESOS" {
+		t.Errorf("expected type MESOS, got %s", container.Type)
+	}
+	if container.Docker != nil {
+		t.Errorf("expected no docker definition on a MESOS container, got %+v", container.Docker)
+	}
+	if container.Mesos == nil {
+		t.Fatal("expected a mesos container definition")
+	}
+	if container.Mesos.Image != nil {
+		t.Errorf("expected no image on a new MESOS container, got %+v", container.Mesos.Image)
+	}
+}
+
+func TestMesosContainerBuildersSetImageAndCredential(t *testing.T) {
+	container := NewMesosContainer()
+	container.Mesos.Container("DOCKER", "library/nginx").SetForcePullImage(true)
+	container.Mesos.SetCredential("principal", "secret")
+
+	data, err := json.Marshal(container.Mesos)
+	if err != nil {
+		t.Fatalf("failed to marshal mesos container: %s", err)
+	}
+
+	expected := `{"image":{"kind":"DOCKER","id":"library/nginx","forcePull":true},"credential":{"principal":"principal","secret":"secret"}}`
+	if got := string(data); got != expected {
+		t.Errorf("expected %s, got %s", expected, got)
+	}
+}
+
+func TestContainerExposePortReturnsErrorForMesosContainers(t *testing.T) {
+	container := NewMesosContainer()
+
+	if _, err := container.ExposePort(80, 0, 0, "tcp"); err == nil {
+		t.Error("expected an error exposing a port on a MESOS container, got nil")
+	}
+}
+
+func TestContainerServicePortIndexReturnsErrorForMesosContainers(t *testing.T) {
+	container := NewMesosContainer()
+
+	if _, err := container.ServicePortIndex(80); err == nil {
+		t.Error("expected an error looking up a service port index on a MESOS container, got nil")
+	}
+}
+
+func TestContainerExposePortDispatchesToDockerForDockerContainers(t *testing.T) {
+	container := NewDockerContainer()
+
+	if _, err := container.ExposePort(80, 8080, 0, "tcp"); err != nil {
+		t.Fatalf("unexpected error exposing a port on a DOCKER container: %s", err)
+	}
+
+	index, err := container.ServicePortIndex(80)
+	if err != nil {
+		t.Fatalf("unexpected error looking up the service port index: %s", err)
+	}
+	if index != 0 {
+		t.Errorf("expected index 0, got %d", index)
+	}
+}
+
+func TestPersistentVolumeAppendsVolumeWithSizeAndType(t *testing.T) {
+	container := NewDockerContainer()
+	container.PersistentVolume("/data", 1024, "mount")
+
+	data, err := json.Marshal(container.Volumes)
+	if err != nil {
+		t.Fatalf("failed to marshal volumes: %s", err)
+	}
+
+	expected := `[{"containerPath":"/data","mode":"RW","persistent":{"size":1024,"type":"mount"}}]`
+	if got := string(data); got != expected {
+		t.Errorf("expected %s, got %s", expected, got)
+	}
+}
+
+func TestExternalVolumeAppendsVolumeWithProviderOptions(t *testing.T) {
+	container := NewDockerContainer()
+	container.ExternalVolume("/data", "myvolume", "dvdi", map[string]string{"dvdi/driver": "rexray"}, "RW")
+
+	data, err := json.Marshal(container.Volumes)
+	if err != nil {
+		t.Fatalf("failed to marshal volumes: %s", err)
+	}
+
+	expected := `[{"containerPath":"/data","mode":"RW","external":{"name":"myvolume","provider":"dvdi","options":{"dvdi/driver":"rexray"}}}]`
+	if got := string(data); got != expected {
+		t.Errorf("expected %s, got %s", expected, got)
+	}
+}
+
+func TestHostAndBridgeModeSetNetwork(t *testing.T) {
+	docker := &Docker{}
+	docker.Host()
+	if docker.Network != "HOST" {
+		t.Errorf("expected network HOST, got %s", docker.Network)
+	}
+
+	docker.BridgeMode()
+	if docker.Network != "BRIDGE" {
+		t.Errorf("expected network BRIDGE, got %s", docker.Network)
+	}
+}
+
+func TestBridgedIsAnAliasForBridgeMode(t *testing.T) {
+	docker := &Docker{}
+	docker.Bridged()
+
+	if docker.Network != "BRIDGE" {
+		t.Errorf("expected Bridged to set network BRIDGE, got %s", docker.Network)
+	}
+}
+
+func TestUserNetworkLeavesHostPortUnsetOnNewMappings(t *testing.T) {
+	docker := &Docker{}
+	docker.UserNetwork("mynet")
+	docker.ExposePort(80, 8080, 0, "tcp")
+
+	expected := `[{"containerPort":80,"protocol":"tcp"}]`
+	if got := portMappingsJSON(t, docker); got != expected {
+		t.Errorf("expected %s, got %s", expected, got)
+	}
+}
+
+func TestExposePortNamedSetsNameAndLabels(t *testing.T) {
+	docker := &Docker{}
+	docker.ExposePortNamed(80, 0, 10000, "tcp", "http", map[string]string{"VIP_0": "/foo:80"})
+
+	expected := `[{"containerPort":80,"hostPort":0,"servicePort":10000,"protocol":"tcp","name":"http","labels":{"VIP_0":"/foo:80"}}]`
+	if got := portMappingsJSON(t, docker); got != expected {
+		t.Errorf("expected %s, got %s", expected, got)
+	}
+}
+
+func TestPortMappingByNameFindsMappingAndAllowsMutation(t *testing.T) {
+	docker := &Docker{}
+	docker.ExposePortNamed(80, 0, 10000, "tcp", "http", nil)
+	docker.ExposePortNamed(443, 0, 10001, "tcp", "https", nil)
+
+	mapping, err := docker.PortMappingByName("https")
+	if err != nil {
+		t.Fatalf("unexpected error finding port mapping: %s", err)
+	}
+	if mapping.ServicePort != 10001 {
+		t.Fatalf("expected service port 10001, got %d", mapping.ServicePort)
+	}
+
+	// mutating through the returned pointer must affect the actual port mapping, not a copy
+	mapping.ServicePort = 20001
+	if (*docker.PortMappings)[1].ServicePort != 20001 {
+		t.Errorf("expected mutation through the returned pointer to affect the underlying slice, got %+v", (*docker.PortMappings)[1])
+	}
+}
+
+func TestPortMappingByNameReturnsErrorWhenNotFound(t *testing.T) {
+	docker := &Docker{}
+	docker.ExposePortNamed(80, 0, 10000, "tcp", "http", nil)
+
+	if _, err := docker.PortMappingByName("missing"); err == nil {
+		t.Error("expected an error for a missing port mapping name, got nil")
+	}
+}
+
+func TestServicePortByNameFindsServicePort(t *testing.T) {
+	docker := &Docker{}
+	docker.ExposePortNamed(80, 0, 10000, "tcp", "http", nil)
+
+	port, err := docker.ServicePortByName("http")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if port != 10000 {
+		t.Errorf("expected service port 10000, got %d", port)
+	}
+}
+
+func TestServicePortByNameReturnsErrorWhenNotFound(t *testing.T) {
+	docker := &Docker{}
+	docker.ExposePortNamed(80, 0, 10000, "tcp", "http", nil)
+
+	if _, err := docker.ServicePortByName("missing"); err == nil {
+		t.Error("expected an error for a missing port mapping name, got nil")
+	}
+}